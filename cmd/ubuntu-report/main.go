@@ -0,0 +1,274 @@
+// Command ubuntu-report collects anonymous hardware and software metrics
+// about the running machine and sends them to the ubuntu-report collector,
+// so that Canonical can get a better picture of the Ubuntu installed base.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ubuntu/ubuntu-report/internal/metrics"
+	"github.com/ubuntu/ubuntu-report/internal/sysmetrics"
+)
+
+func generateRootCmd() *cobra.Command {
+	var verbose int
+
+	rootCmd := &cobra.Command{
+		Use:   "ubuntu-report",
+		Short: "Report hardware and software information to Ubuntu",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			setVerbosity(verbose)
+		},
+	}
+	rootCmd.PersistentFlags().CountVarP(&verbose, "verbose", "v", "issue INFO (-v) and DEBUG (-vv) output")
+
+	rootCmd.AddCommand(generateShowCmd())
+	rootCmd.AddCommand(generateSendCmd())
+	rootCmd.AddCommand(generateFlushCmd())
+	rootCmd.AddCommand(generateValidateCmd())
+
+	return rootCmd
+}
+
+func setVerbosity(level int) {
+	switch level {
+	case 0:
+		logrus.SetLevel(logrus.ErrorLevel)
+	case 1:
+		logrus.SetLevel(logrus.InfoLevel)
+	default:
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+}
+
+func generateShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Only display the report, without sending it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := sysmetrics.Collect(metrics.CategoryFilter{})
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+}
+
+func generateSendCmd() *cobra.Command {
+	var url string
+	var stdout bool
+	var maxAttempts int
+	var timeout time.Duration
+	var exclude []string
+	var includeOnly []string
+	var pins []string
+	var clientCert string
+	var clientKey string
+	var insecureSkipVerify bool
+
+	cmd := &cobra.Command{
+		Use:   "send [yes|no]",
+		Short: "Send (or don't) the report to Ubuntu",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			answer := ""
+			if len(args) > 0 {
+				answer = args[0]
+			} else {
+				answer = promptOptIn()
+				if answer != "no" && len(exclude) == 0 && len(includeOnly) == 0 {
+					exclude = promptCategoryExclusion()
+				}
+			}
+
+			optOut := answer == "no"
+
+			var data []byte
+			if optOut {
+				data = []byte(sysmetrics.OptOutJSON)
+			} else {
+				d, err := sysmetrics.Collect(metrics.CategoryFilter{Exclude: exclude, IncludeOnly: includeOnly})
+				if err != nil {
+					return err
+				}
+				data = d
+			}
+
+			if stdout {
+				fmt.Println(string(data))
+				return nil
+			}
+
+			return sysmetrics.Report(url, data, sysmetrics.SendOptions{
+				MaxAttempts: maxAttempts,
+				Timeout:     timeout,
+				TLS: sysmetrics.TLSOptions{
+					PinsSHA256:         pins,
+					ClientCertFile:     clientCert,
+					ClientKeyFile:      clientKey,
+					InsecureSkipVerify: insecureSkipVerify,
+				},
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "collector URL to send the report to (defaults to "+sysmetrics.BaseURL+")")
+	cmd.Flags().BoolVar(&stdout, "stdout", false, "print the report that would be sent to stdout instead of sending it")
+	cmd.Flags().IntVar(&maxAttempts, "max-attempts", sysmetrics.DefaultMaxAttempts, "number of attempts before queuing the report for a later send")
+	cmd.Flags().DurationVar(&timeout, "timeout", sysmetrics.DefaultTimeout, "HTTP timeout for each attempt")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", nil, "comma-separated list of categories to omit from the report, e.g. gpu,screen,autologin,partitions (repeatable)")
+	cmd.Flags().StringSliceVar(&includeOnly, "include-only", nil, "comma-separated list of categories to keep in the report, omitting everything else (repeatable)")
+	cmd.Flags().StringSliceVar(&pins, "pin-sha256", nil, "base64-encoded SHA-256 hash of an accepted collector certificate SPKI, pinning the connection (repeatable)")
+	cmd.Flags().StringVar(&clientCert, "client-cert", "", "client certificate to present to the collector (requires --client-key)")
+	cmd.Flags().StringVar(&clientKey, "client-key", "", "private key matching --client-cert")
+	cmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "skip collector hostname and certificate chain verification (pinning, if set, still applies)")
+
+	return cmd
+}
+
+func generateFlushCmd() *cobra.Command {
+	var url string
+	var maxAttempts int
+	var timeout time.Duration
+	var pins []string
+	var clientCert string
+	var clientKey string
+	var insecureSkipVerify bool
+
+	cmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Retry sending any reports queued from a previous failed send",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sysmetrics.Flush(url, sysmetrics.SendOptions{
+				MaxAttempts: maxAttempts,
+				Timeout:     timeout,
+				TLS: sysmetrics.TLSOptions{
+					PinsSHA256:         pins,
+					ClientCertFile:     clientCert,
+					ClientKeyFile:      clientKey,
+					InsecureSkipVerify: insecureSkipVerify,
+				},
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "collector URL to send the queued reports to (defaults to "+sysmetrics.BaseURL+")")
+	cmd.Flags().IntVar(&maxAttempts, "max-attempts", sysmetrics.DefaultMaxAttempts, "number of attempts before giving up on a queued report")
+	cmd.Flags().DurationVar(&timeout, "timeout", sysmetrics.DefaultTimeout, "HTTP timeout for each attempt")
+	cmd.Flags().StringSliceVar(&pins, "pin-sha256", nil, "base64-encoded SHA-256 hash of an accepted collector certificate SPKI, pinning the connection (repeatable)")
+	cmd.Flags().StringVar(&clientCert, "client-cert", "", "client certificate to present to the collector (requires --client-key)")
+	cmd.Flags().StringVar(&clientKey, "client-key", "", "private key matching --client-cert")
+	cmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "skip collector hostname and certificate chain verification (pinning, if set, still applies)")
+
+	return cmd
+}
+
+func generateValidateCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "validate [path]",
+		Short: "Check that a stored or piped report is well-formed",
+		Args:  cobra.MaximumNArgs(1),
+		// A failed validation is the expected outcome for a build gate, not
+		// a usage mistake: don't pile cobra's usage block and a duplicate
+		// "Error:" line on top of the --format summary we already printed.
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "-"
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			var data []byte
+			var err error
+			if path == "-" {
+				data, err = ioutil.ReadAll(os.Stdin)
+			} else {
+				data, err = ioutil.ReadFile(path)
+			}
+			if err != nil {
+				return err
+			}
+
+			result := sysmetrics.Validate(data)
+
+			switch format {
+			case "json":
+				out, err := json.Marshal(result)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+			case "text":
+				if result.Valid {
+					fmt.Println("valid report")
+				} else {
+					for _, issue := range result.Issues {
+						if issue.Field != "" {
+							fmt.Printf("%s: %s\n", issue.Field, issue.Issue)
+						} else {
+							fmt.Println(issue.Issue)
+						}
+					}
+				}
+			default:
+				return fmt.Errorf("unknown format %q, expected json or text", format)
+			}
+
+			if !result.Valid {
+				return fmt.Errorf("report failed validation with %d issue(s)", len(result.Issues))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "output format for the validation summary: json or text")
+
+	return cmd
+}
+
+// promptOptIn interactively asks the user for consent when no explicit
+// answer was given on the command line.
+func promptOptIn() string {
+	fmt.Println("No report has been sent yet. Do you agree to report this info? [yes/no]")
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		return strings.ToLower(strings.TrimSpace(scanner.Text()))
+	}
+	return "no"
+}
+
+// promptCategoryExclusion interactively asks the user whether any category
+// should be left out of an otherwise accepted report.
+func promptCategoryExclusion() []string {
+	fmt.Println("Any category you want to exclude from the report? (comma-separated, e.g. gpu,screen, empty for none)")
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return nil
+	}
+	answer := strings.TrimSpace(scanner.Text())
+	if answer == "" {
+		return nil
+	}
+	return strings.Split(answer, ",")
+}
+
+func main() {
+	if err := generateRootCmd().Execute(); err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -122,18 +125,249 @@ func TestVerbosity(t *testing.T) {
 	}
 }
 
+func TestSendStdout(t *testing.T) {
+	helper.SkipIfShort(t)
+	a := helper.Asserter{T: t}
+
+	out, tearDown := helper.TempDir(t)
+	defer tearDown()
+	defer helper.ChangeEnv("XDG_CACHE_HOME", out)()
+	reportDir := filepath.Join(out, "ubuntu-report")
+
+	serverHit := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverHit = true
+	}))
+	defer ts.Close()
+
+	stdout, restoreStdout := helper.CaptureStdout(t)
+	defer restoreStdout()
+
+	cmd := generateRootCmd()
+	cmd.SetArgs([]string{"send", "yes", "--url", ts.URL, "--stdout"})
+
+	cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
+		var err error
+		_, err = cmd.ExecuteC()
+		restoreStdout() // close stdout to release ReadAll()
+		return err
+	})
+
+	if err := <-cmdErrs; err != nil {
+		t.Fatal("got an error when expecting none:", err)
+	}
+
+	a.Equal(serverHit, false)
+
+	got, err := ioutil.ReadAll(stdout)
+	if err != nil {
+		t.Error("couldn't read from stdout", err)
+	}
+	if !strings.Contains(string(got), expectedReportItem) {
+		t.Errorf("Expected %s to be in output, but got: %s", expectedReportItem, string(got))
+	}
+
+	if _, err := os.Stat(reportDir); err == nil {
+		t.Errorf("expected no report cache directory to be created at %s, but it was", reportDir)
+	}
+}
+
+func TestSendQueuesOnFailureAndFlushesLater(t *testing.T) {
+	helper.SkipIfShort(t)
+	a := helper.Asserter{T: t}
+
+	out, tearDown := helper.TempDir(t)
+	defer tearDown()
+	defer helper.ChangeEnv("XDG_CACHE_HOME", out)()
+	reportDir := filepath.Join(out, "ubuntu-report")
+	pendingDir := filepath.Join(reportDir, "pending")
+
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// First attempt: the collector is down, the report should be queued
+	// rather than lost.
+	cmd := generateRootCmd()
+	cmd.SetArgs([]string{"send", "yes", "--url", ts.URL, "--max-attempts", "1"})
+	cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+	if err := <-cmdErrs; err != nil {
+		t.Fatal("got an error when expecting none:", err)
+	}
+
+	pending, err := ioutil.ReadDir(pendingDir)
+	if err != nil {
+		t.Fatalf("couldn't read pending directory %s: %v", pendingDir, err)
+	}
+	a.Equal(len(pending), 1)
+	if f := helperFindReportFile(t, reportDir); f != "" {
+		t.Errorf("expected no report file to be written while the collector was down, got %s", f)
+	}
+
+	// Second attempt: the collector is back up, the queued report should be
+	// flushed and removed.
+	cmd = generateRootCmd()
+	cmd.SetArgs([]string{"send", "yes", "--url", ts.URL, "--max-attempts", "1"})
+	cmdErrs = helper.RunFunctionWithTimeout(t, func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+	if err := <-cmdErrs; err != nil {
+		t.Fatal("got an error when expecting none:", err)
+	}
+
+	pending, err = ioutil.ReadDir(pendingDir)
+	if err != nil {
+		t.Fatalf("couldn't read pending directory %s: %v", pendingDir, err)
+	}
+	a.Equal(len(pending), 0)
+
+	p := filepath.Join(reportDir, helper.FindInDirectory(t, "", reportDir))
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatalf("couldn't open report file %s", reportDir)
+	}
+	if !strings.Contains(string(data), expectedReportItem) {
+		t.Errorf("we expected to find %s in report file, got: %s", expectedReportItem, string(data))
+	}
+}
+
+func TestSendRetriesWithinSingleInvocation(t *testing.T) {
+	helper.SkipIfShort(t)
+	a := helper.Asserter{T: t}
+
+	out, tearDown := helper.TempDir(t)
+	defer tearDown()
+	defer helper.ChangeEnv("XDG_CACHE_HOME", out)()
+	reportDir := filepath.Join(out, "ubuntu-report")
+	pendingDir := filepath.Join(reportDir, "pending")
+
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// The collector fails once, then recovers: a single send invocation
+	// with room for more than one attempt should retry in-process and
+	// succeed, rather than queuing the report for a later flush.
+	cmd := generateRootCmd()
+	cmd.SetArgs([]string{"send", "yes", "--url", ts.URL, "--max-attempts", "2"})
+	cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+	if err := <-cmdErrs; err != nil {
+		t.Fatal("got an error when expecting none:", err)
+	}
+
+	a.Equal(hits, 2)
+	if pending, err := ioutil.ReadDir(pendingDir); err == nil {
+		a.Equal(len(pending), 0)
+	}
+
+	p := filepath.Join(reportDir, helper.FindInDirectory(t, "", reportDir))
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatalf("couldn't open report file %s", reportDir)
+	}
+	if !strings.Contains(string(data), expectedReportItem) {
+		t.Errorf("we expected to find %s in report file, got: %s", expectedReportItem, string(data))
+	}
+}
+
+func TestFlush(t *testing.T) {
+	helper.SkipIfShort(t)
+	a := helper.Asserter{T: t}
+
+	out, tearDown := helper.TempDir(t)
+	defer tearDown()
+	defer helper.ChangeEnv("XDG_CACHE_HOME", out)()
+	pendingDir := filepath.Join(out, "ubuntu-report", "pending")
+	if err := os.MkdirAll(pendingDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	queuedReport := filepath.Join(pendingDir, "1.json")
+	if err := ioutil.WriteFile(queuedReport, []byte(`{"Version": "1"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var wirePayload string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		wirePayload = string(body)
+	}))
+	defer ts.Close()
+
+	cmd := generateRootCmd()
+	cmd.SetArgs([]string{"flush", "--url", ts.URL})
+	cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+	if err := <-cmdErrs; err != nil {
+		t.Fatal("got an error when expecting none:", err)
+	}
+
+	if !strings.Contains(wirePayload, expectedReportItem) {
+		t.Errorf("expected the queued report to reach the collector, got: %s", wirePayload)
+	}
+	pending, err := ioutil.ReadDir(pendingDir)
+	if err != nil {
+		t.Fatalf("couldn't read pending directory %s: %v", pendingDir, err)
+	}
+	a.Equal(len(pending), 0)
+	if _, err := os.Stat(queuedReport); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed once flushed", queuedReport)
+	}
+}
+
+// helperFindReportFile returns the report file name in dir, or "" if dir
+// doesn't exist yet.
+func helperFindReportFile(t *testing.T, dir string) string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			return f.Name()
+		}
+	}
+	return ""
+}
+
 func TestSend(t *testing.T) {
 	helper.SkipIfShort(t)
 
 	testCases := []struct {
-		name   string
-		answer string
+		name      string
+		answer    string
+		extraArgs []string
 
 		shouldHitServer bool
+		wantExcluded    string
 		wantErr         bool
 	}{
-		{"regular report auto", "yes", true, false},
-		{"regular report opt-out", "no", true, false},
+		{"regular report auto", "yes", nil, true, "", false},
+		{"regular report opt-out", "no", nil, true, "", false},
+		{"regular report excluding partitions", "yes", []string{"--exclude", "partitions"}, true, `"Excluded":["Partitions"]`, false},
 	}
 	for _, tc := range testCases {
 		tc := tc // capture range variable for parallel execution
@@ -148,13 +382,16 @@ func TestSend(t *testing.T) {
 			// and we don't really control /etc/os-release version and id.
 			// Same for report file
 			serverHit := false
+			var wirePayload string
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				serverHit = true
+				body, _ := ioutil.ReadAll(r.Body)
+				wirePayload = string(body)
 			}))
 			defer ts.Close()
 
 			cmd := generateRootCmd()
-			args := []string{"send", tc.answer, "--url", ts.URL}
+			args := append([]string{"send", tc.answer, "--url", ts.URL}, tc.extraArgs...)
 			cmd.SetArgs(args)
 
 			cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
@@ -185,6 +422,146 @@ func TestSend(t *testing.T) {
 					t.Errorf("we expected to find %s in report file, got: %s", optOutJSON, d)
 				}
 			}
+
+			if tc.wantExcluded != "" {
+				if !strings.Contains(wirePayload, tc.wantExcluded) {
+					t.Errorf("expected %s in wire payload, got: %s", tc.wantExcluded, wirePayload)
+				}
+				if !strings.Contains(d, tc.wantExcluded) {
+					t.Errorf("expected %s in report file, got: %s", tc.wantExcluded, d)
+				}
+			}
+		})
+	}
+}
+
+func TestSendTLSPinning(t *testing.T) {
+	helper.SkipIfShort(t)
+
+	serverHit := false
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverHit = true
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+	validPin := base64.StdEncoding.EncodeToString(sum[:])
+	wrongPin := base64.StdEncoding.EncodeToString(sha256.New().Sum(nil))
+
+	testCases := []struct {
+		name       string
+		pin        string
+		wantQueued bool
+	}{
+		{"matching pin", validPin, false},
+		{"mismatched pin", wrongPin, true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			a := helper.Asserter{T: t}
+			serverHit = false
+
+			out, tearDown := helper.TempDir(t)
+			defer tearDown()
+			defer helper.ChangeEnv("XDG_CACHE_HOME", out)()
+			pendingDir := filepath.Join(out, "ubuntu-report", "pending")
+
+			cmd := generateRootCmd()
+			cmd.SetArgs([]string{
+				"send", "yes",
+				"--url", ts.URL,
+				"--pin-sha256", tc.pin,
+				"--insecure-skip-verify",
+				"--max-attempts", "1",
+			})
+
+			cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if err := <-cmdErrs; err != nil {
+				t.Fatal("got an error when expecting none:", err)
+			}
+
+			if !tc.wantQueued {
+				a.Equal(serverHit, true)
+				return
+			}
+
+			// the TLS handshake never completed, so the collector was
+			// never actually reached, and the report was spooled instead.
+			a.Equal(serverHit, false)
+			pending, err := ioutil.ReadDir(pendingDir)
+			if err != nil {
+				t.Fatalf("couldn't read pending directory %s: %v", pendingDir, err)
+			}
+			a.Equal(len(pending), 1)
+		})
+	}
+}
+
+const validReport = `{
+	"Version": "1",
+	"OEM": {"Vendor": "Dell", "Product": "XPS 13"},
+	"BIOS": {"Vendor": "Dell", "Version": "2.3.0"},
+	"CPU": {"Cores": 8, "Model": "Intel i7"},
+	"Arch": "amd64",
+	"GPU": [{"Vendor": "Intel", "Model": "UHD"}],
+	"RAM": 16,
+	"Disks": [{"Size": 512}],
+	"Partitions": [{"Size": 500, "Type": "ext2/ext3/ext4"}],
+	"Screens": [{"Resolution": "1920x1080"}],
+	"Autologin": false,
+	"LivePatch": false,
+	"Session": {"DE": "GNOME", "Name": "ubuntu", "Type": "x11"},
+	"Locale": "en_US",
+	"Timezone": "Europe/London",
+	"Install": {},
+	"Upgrade": false
+}`
+
+func TestValidate(t *testing.T) {
+	helper.SkipIfShort(t)
+
+	testCases := []struct {
+		name    string
+		report  string
+		wantErr bool
+	}{
+		{"valid report", validReport, false},
+		{"valid opt-out", optOutJSON, false},
+		{"opt-out with extra keys", `{"OptOut": true, "Version": "1"}`, true},
+		{"missing required key", `{"Version": "1"}`, true},
+		{"empty session fields", strings.Replace(validReport, `"DE": "GNOME"`, `"DE": ""`, 1), true},
+		{"not json", `not json at all`, true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, tearDown := helper.TempDir(t)
+			defer tearDown()
+			path := filepath.Join(dir, "report.json")
+			if err := ioutil.WriteFile(path, []byte(tc.report), 0600); err != nil {
+				t.Fatal("couldn't write report fixture:", err)
+			}
+
+			cmd := generateRootCmd()
+			cmd.SetArgs([]string{"validate", path})
+
+			cmdErrs := helper.RunFunctionWithTimeout(t, func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			err := <-cmdErrs
+			if tc.wantErr && err == nil {
+				t.Error("expected an error, got none")
+			}
+			if !tc.wantErr && err != nil {
+				t.Error("expected no error, got:", err)
+			}
 		})
 	}
 }
@@ -0,0 +1,156 @@
+// Package helper provides utilities shared between the various test suites
+// of ubuntu-report.
+package helper
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Asserter embeds a *testing.T to offer convenience comparison helpers.
+type Asserter struct {
+	*testing.T
+}
+
+// Equal checks that got and want are equal, failing the test otherwise.
+func (a Asserter) Equal(got, want interface{}) {
+	a.Helper()
+	if got != want {
+		a.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// SkipIfShort skips the current test when running with `go test -short`.
+func SkipIfShort(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping test in short mode")
+	}
+}
+
+// CaptureStdout redirects os.Stdout to a pipe, returning a reader of
+// everything written to it until the returned function is called.
+func CaptureStdout(t *testing.T) (io.Reader, func()) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal("couldn't create pipe for stdout capture:", err)
+	}
+
+	orig := os.Stdout
+	os.Stdout = w
+
+	closed := false
+	return r, func() {
+		if closed {
+			return
+		}
+		closed = true
+		w.Close()
+		os.Stdout = orig
+	}
+}
+
+// CaptureLogs redirects the logrus output to a pipe, returning a reader of
+// everything logged until the returned function is called.
+func CaptureLogs(t *testing.T) (io.Reader, func()) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal("couldn't create pipe for log capture:", err)
+	}
+
+	orig := logrus.StandardLogger().Out
+	logrus.SetOutput(w)
+
+	closed := false
+	return r, func() {
+		if closed {
+			return
+		}
+		closed = true
+		w.Close()
+		logrus.SetOutput(orig)
+	}
+}
+
+// RunFunctionWithTimeout runs f in a goroutine and forwards its error on the
+// returned channel, failing the test if it didn't complete in time.
+func RunFunctionWithTimeout(t *testing.T, f func() error) chan error {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		errs <- f()
+		close(done)
+	}()
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("function didn't complete within the allowed time")
+		}
+	}()
+
+	return errs
+}
+
+// TempDir creates a temporary directory, returning its path and a function
+// removing it.
+func TempDir(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "ubuntu-report-test")
+	if err != nil {
+		t.Fatal("couldn't create temporary directory:", err)
+	}
+	return dir, func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Error("couldn't remove temporary directory:", err)
+		}
+	}
+}
+
+// ChangeEnv sets the environment variable key to value, returning a function
+// restoring its previous value (or unsetting it if it wasn't set).
+func ChangeEnv(key, value string) func() {
+	orig, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if had {
+			os.Setenv(key, orig)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+// FindInDirectory returns the name of the first file matching prefix in dir.
+func FindInDirectory(t *testing.T, prefix, dir string) string {
+	t.Helper()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("couldn't read directory %s: %v", dir, err)
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if prefix == "" || strings.HasPrefix(f.Name(), prefix) {
+			return f.Name()
+		}
+	}
+	t.Fatalf("couldn't find any file with prefix %q in %s", prefix, dir)
+	return ""
+}
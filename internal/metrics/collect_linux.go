@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// partitionSize returns the total size of the filesystem mounted at
+// mountpoint, in gigabytes, or 0 if it can't be determined.
+func partitionSize(mountpoint string) float64 {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(mountpoint, &stat); err != nil {
+		return 0
+	}
+	return float64(stat.Blocks*uint64(stat.Bsize)) / (1024 * 1024 * 1024)
+}
+
+// readSysFile returns the trimmed content of a file under the collector's
+// root, or "" if it doesn't exist.
+func (c Collector) readSysFile(path string) string {
+	data, err := ioutil.ReadFile(filepath.Join(c.root, path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (c Collector) collectDMI(r *Report) error {
+	r.OEM = OEM{
+		Vendor:  c.readSysFile("sys/class/dmi/id/sys_vendor"),
+		Product: c.readSysFile("sys/class/dmi/id/product_name"),
+		Family:  c.readSysFile("sys/class/dmi/id/product_family"),
+	}
+	r.BIOS = BIOS{
+		Vendor:  c.readSysFile("sys/class/dmi/id/bios_vendor"),
+		Version: c.readSysFile("sys/class/dmi/id/bios_version"),
+	}
+	return nil
+}
+
+func (c Collector) collectCPU(r *Report) error {
+	f, err := os.Open(filepath.Join(c.root, "proc/cpuinfo"))
+	if err != nil {
+		r.CPU = CPU{Cores: runtime.NumCPU()}
+		return err
+	}
+	defer f.Close()
+
+	cores := 0
+	model := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := scanner.Text()
+		switch {
+		case strings.HasPrefix(l, "processor"):
+			cores++
+		case strings.HasPrefix(l, "model name") && model == "":
+			parts := strings.SplitN(l, ":", 2)
+			if len(parts) == 2 {
+				model = strings.TrimSpace(parts[1])
+			}
+		}
+	}
+	if cores == 0 {
+		cores = runtime.NumCPU()
+	}
+	r.CPU = CPU{Cores: cores, Model: model}
+	return scanner.Err()
+}
+
+func (c Collector) collectGPU(r *Report) error {
+	// TODO: not implemented yet, so GPU is always omitted. Enumerating GPUs
+	// needs either libpci or walking /sys/bus/pci/devices for display-class
+	// controllers.
+	logrus.Debug("GPU collection not implemented, omitting category")
+	return nil
+}
+
+func (c Collector) collectScreens(r *Report) error {
+	// TODO: not implemented yet, so Screens is always omitted. This needs a
+	// running X/Wayland session to query connected displays.
+	logrus.Debug("Screen collection not implemented, omitting category")
+	return nil
+}
+
+func (c Collector) collectAutologin(r *Report) error {
+	// TODO: not implemented yet, so Autologin is always false. This needs
+	// parsing the display manager's configuration (gdm, lightdm, ...).
+	logrus.Debug("autologin collection not implemented, reporting false")
+	return nil
+}
+
+func (c Collector) collectPartitions(r *Report) error {
+	f, err := os.Open(filepath.Join(c.root, "proc/mounts"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mountpoint, fstype := fields[1], fields[2]
+		if !strings.HasPrefix(mountpoint, "/") || strings.HasPrefix(mountpoint, "/proc") ||
+			strings.HasPrefix(mountpoint, "/sys") || strings.HasPrefix(mountpoint, "/dev") {
+			continue
+		}
+		r.Partitions = append(r.Partitions, Partition{
+			Size: partitionSize(mountpoint),
+			Type: fsTypeFromMagic(mountpoint, fstype),
+		})
+	}
+	return scanner.Err()
+}
+
+func (c Collector) collectSession(r *Report) error {
+	r.Session = Session{
+		DE:   os.Getenv("XDG_CURRENT_DESKTOP"),
+		Name: os.Getenv("DESKTOP_SESSION"),
+		Type: os.Getenv("XDG_SESSION_TYPE"),
+	}
+	return nil
+}
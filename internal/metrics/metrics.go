@@ -0,0 +1,253 @@
+// Package metrics collects anonymous hardware and software information about
+// the running system and serializes it to the JSON format sent to the
+// ubuntu-report collector.
+package metrics
+
+import (
+	"encoding/json"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Report is the root object marshaled and sent to the collector.
+type Report struct {
+	Version string `json:"Version"`
+
+	OEM        OEM         `json:"OEM"`
+	BIOS       BIOS        `json:"BIOS"`
+	CPU        CPU         `json:"CPU"`
+	Arch       string      `json:"Arch"`
+	GPU        []GPU       `json:"GPU,omitempty"`
+	RAM        float64     `json:"RAM"`
+	Disks      []Disk      `json:"Disks,omitempty"`
+	Partitions []Partition `json:"Partitions,omitempty"`
+	Screens    []Screen    `json:"Screens,omitempty"`
+	Autologin  bool        `json:"Autologin"`
+	LivePatch  bool        `json:"LivePatch"`
+	Session    Session     `json:"Session"`
+	Locale     string      `json:"Locale"`
+	Timezone   string      `json:"Timezone"`
+	Install    Install     `json:"Install"`
+	Upgrade    bool        `json:"Upgrade"`
+
+	// Excluded lists the categories that were dropped from this report on
+	// the reporter's request, so that the collector and any re-send of the
+	// cached report know it is intentionally incomplete.
+	Excluded []string `json:"Excluded,omitempty"`
+}
+
+// OEM holds the vendor and product identification of the machine.
+type OEM struct {
+	Vendor  string `json:"Vendor"`
+	Product string `json:"Product"`
+	Family  string `json:"Family,omitempty"`
+}
+
+// BIOS holds the firmware vendor and version.
+type BIOS struct {
+	Vendor  string `json:"Vendor"`
+	Version string `json:"Version"`
+}
+
+// CPU holds the processor identification.
+type CPU struct {
+	OpMode string `json:"OpMode,omitempty"`
+	Cores  int    `json:"Cores"`
+	Model  string `json:"Model,omitempty"`
+}
+
+// GPU holds one graphic card identification.
+type GPU struct {
+	Vendor string `json:"Vendor"`
+	Model  string `json:"Model"`
+}
+
+// Disk holds one physical disk size, in GB.
+type Disk struct {
+	Size float64 `json:"Size"`
+}
+
+// Partition holds a mountpoint's size (in GB) and the filesystem type it is
+// formatted with.
+type Partition struct {
+	Size float64 `json:"Size"`
+	Type string  `json:"Type"`
+}
+
+// Screen holds the resolution of one connected display.
+type Screen struct {
+	Size       string `json:"Size,omitempty"`
+	Resolution string `json:"Resolution"`
+}
+
+// Session holds the current desktop session information.
+type Session struct {
+	DE   string `json:"DE"`
+	Name string `json:"Name"`
+	Type string `json:"Type"`
+}
+
+// Install holds information gathered at install time, when available.
+type Install struct {
+	Media    string `json:"Media,omitempty"`
+	Type     string `json:"Type,omitempty"`
+	OEM      bool   `json:"OEM,omitempty"`
+	Stages   string `json:"Stages,omitempty"`
+	Duration int    `json:"Duration,omitempty"`
+}
+
+// categoryAliases maps the lowercase category names accepted on the command
+// line to the Report JSON key they control.
+var categoryAliases = map[string]string{
+	"oem":        "OEM",
+	"bios":       "BIOS",
+	"cpu":        "CPU",
+	"gpu":        "GPU",
+	"ram":        "RAM",
+	"disk":       "Disks",
+	"disks":      "Disks",
+	"partition":  "Partitions",
+	"partitions": "Partitions",
+	"screen":     "Screens",
+	"screens":    "Screens",
+	"autologin":  "Autologin",
+	"livepatch":  "LivePatch",
+	"session":    "Session",
+	"locale":     "Locale",
+	"timezone":   "Timezone",
+	"install":    "Install",
+	"upgrade":    "Upgrade",
+}
+
+// CategoryFilter selects which top-level categories of a Report are kept.
+// Exclude drops the named categories; IncludeOnly, when non-empty, drops
+// every category except the ones it names. IncludeOnly takes precedence.
+// The Version and Arch categories are always kept.
+type CategoryFilter struct {
+	Exclude     []string
+	IncludeOnly []string
+}
+
+// ExcludableCategories returns the canonical Report JSON keys that --exclude
+// and --include-only can name. Version (and Arch) are never in this set:
+// they aren't aliased in categoryAliases, so filterReport can't drop them
+// and a report claiming to exclude them should not be trusted.
+func ExcludableCategories() []string {
+	seen := make(map[string]bool, len(categoryAliases))
+	var categories []string
+	for _, key := range categoryAliases {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		categories = append(categories, key)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// resolveCategories turns the user-provided category names into their canonical
+// Report JSON key, ignoring names it doesn't recognize.
+func resolveCategories(names []string) map[string]bool {
+	resolved := make(map[string]bool, len(names))
+	for _, n := range names {
+		if key, ok := categoryAliases[strings.ToLower(n)]; ok {
+			resolved[key] = true
+		}
+	}
+	return resolved
+}
+
+// Collector gathers system information into a Report.
+type Collector struct {
+	// root overrides the root filesystem used to look up sysfs and procfs
+	// entries, only ever set by tests.
+	root string
+
+	filter CategoryFilter
+}
+
+// New returns a Collector reading the current machine, applying filter to
+// the resulting report.
+func New(filter CategoryFilter) Collector {
+	return Collector{root: "/", filter: filter}
+}
+
+// Collect gathers the current system metrics and returns them already
+// filtered and serialized to JSON.
+func (c Collector) Collect() (json.RawMessage, error) {
+	r := Report{
+		Version: "1",
+		Arch:    runtime.GOARCH,
+	}
+	logrus.Debug("collecting system metrics")
+
+	if err := c.collectDMI(&r); err != nil {
+		logrus.Debug("couldn't collect DMI info:", err)
+	}
+	if err := c.collectCPU(&r); err != nil {
+		logrus.Debug("couldn't collect CPU info:", err)
+	}
+	if err := c.collectGPU(&r); err != nil {
+		logrus.Info("GPU info:", err)
+	}
+	if err := c.collectScreens(&r); err != nil {
+		logrus.Info("Screen info:", err)
+	}
+	if err := c.collectAutologin(&r); err != nil {
+		logrus.Info("autologin information:", err)
+	}
+	if err := c.collectPartitions(&r); err != nil {
+		logrus.Debug("couldn't collect partitions info:", err)
+	}
+	if err := c.collectSession(&r); err != nil {
+		logrus.Debug("couldn't collect session info:", err)
+	}
+
+	return filterReport(r, c.filter)
+}
+
+// filterReport marshals r, dropping the categories requested by filter
+// (rather than merely zeroing their value), and records which ones were
+// dropped in the Excluded field so that a re-send from the cache stays
+// reproducible.
+func filterReport(r Report, filter CategoryFilter) (json.RawMessage, error) {
+	exclude := resolveCategories(filter.Exclude)
+	includeOnly := resolveCategories(filter.IncludeOnly)
+	if len(exclude) == 0 && len(includeOnly) == 0 {
+		return json.Marshal(r)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	var excluded []string
+	for k := range m {
+		if k == "Version" || k == "Arch" || k == "Excluded" {
+			continue
+		}
+		if (len(includeOnly) > 0 && !includeOnly[k]) || exclude[k] {
+			delete(m, k)
+			excluded = append(excluded, k)
+		}
+	}
+	sort.Strings(excluded)
+	if len(excluded) > 0 {
+		excludedJSON, err := json.Marshal(excluded)
+		if err != nil {
+			return nil, err
+		}
+		m["Excluded"] = excludedJSON
+	}
+
+	return json.Marshal(m)
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import "golang.org/x/sys/unix"
+
+// fsTypeMagic maps a statfs Type magic number to its canonical filesystem
+// name. See statfs(2) and the various filesystems' super_magic constants.
+var fsTypeMagic = map[int64]string{
+	0x2fc12fc1: "zfs",
+	0x9123683e: "btrfs",
+	0x58465342: "xfs",
+	0xf2f52010: "f2fs",
+	0x794c7630: "overlayfs",
+	0x01021994: "tmpfs",
+	0xef53:     "ext2/ext3/ext4",
+}
+
+// statfs is a seam over unix.Statfs so that tests can exercise
+// fsTypeFromMagic without real mountpoints.
+var statfs = unix.Statfs
+
+// fsTypeFromMagic returns the canonical filesystem name backing mountpoint,
+// determined from the magic number reported by statfs(2). It falls back to
+// fallback (typically the type reported by /proc/mounts) when statfs fails
+// or the magic number isn't one we recognize.
+func fsTypeFromMagic(mountpoint, fallback string) string {
+	var stat unix.Statfs_t
+	if err := statfs(mountpoint, &stat); err != nil {
+		return fallback
+	}
+	if name, ok := fsTypeMagic[int64(stat.Type)]; ok {
+		return name
+	}
+	return fallback
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFsTypeFromMagic(t *testing.T) {
+	testCases := []struct {
+		name    string
+		magic   int64
+		statErr error
+		want    string
+	}{
+		{"zfs", 0x2fc12fc1, nil, "zfs"},
+		{"btrfs", 0x9123683e, nil, "btrfs"},
+		{"xfs", 0x58465342, nil, "xfs"},
+		{"f2fs", 0xf2f52010, nil, "f2fs"},
+		{"overlayfs", 0x794c7630, nil, "overlayfs"},
+		{"tmpfs", 0x01021994, nil, "tmpfs"},
+		{"ext", 0xef53, nil, "ext2/ext3/ext4"},
+		{"unknown magic falls back", 0xdeadbeef, nil, "fallback"},
+		{"statfs error falls back", 0, unix.ENOENT, "fallback"},
+	}
+
+	defer func() { statfs = unix.Statfs }()
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			statfs = func(path string, buf *unix.Statfs_t) error {
+				if tc.statErr != nil {
+					return tc.statErr
+				}
+				buf.Type = int64(tc.magic)
+				return nil
+			}
+
+			if got := fsTypeFromMagic("/some/mountpoint", "fallback"); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package sysmetrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times a report is attempted,
+	// first attempt included, before being spooled for a later attempt.
+	DefaultMaxAttempts = 5
+	// DefaultTimeout is the per-request HTTP timeout used when none is set.
+	DefaultTimeout = 30 * time.Second
+
+	backoffCap = 5 * time.Minute
+)
+
+// backoffBase is the initial exponential-backoff delay. It's a var rather
+// than a const so tests can shrink it instead of actually sleeping seconds
+// between retries.
+var backoffBase = 2 * time.Second
+
+// SendOptions controls the retry and transport behavior of Report and
+// Flush.
+type SendOptions struct {
+	MaxAttempts int
+	Timeout     time.Duration
+	TLS         TLSOptions
+}
+
+// withDefaults fills the zero-value fields of o with their defaults.
+func (o SendOptions) withDefaults() SendOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	return o
+}
+
+// pendingDir returns the directory queued reports are spooled into, creating
+// it if needed.
+func pendingDir() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "pending")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// enqueue spools data under the pending directory with a timestamped
+// filename, so that it can be retried by a later invocation of send or
+// flush.
+func enqueue(data []byte) (string, error) {
+	dir, err := pendingDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", time.Now().UnixNano()))
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// backoffDelay returns the exponential backoff delay before the given
+// (0-indexed) retry attempt, with a cap and jitter to avoid thundering herd.
+func backoffDelay(attempt int) time.Duration {
+	delay := backoffBase * time.Duration(1<<uint(attempt))
+	if delay > backoffCap || delay <= 0 {
+		delay = backoffCap
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoffBase)))
+	return delay + jitter
+}
+
+// sendWithRetry attempts to POST data to url up to opts.MaxAttempts times,
+// backing off exponentially between attempts.
+func sendWithRetry(client *http.Client, url string, data []byte, opts SendOptions) error {
+	var err error
+	for attempt := 0; attempt < opts.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(attempt - 1)
+			logrus.Debugf("retrying report submission in %s (attempt %d/%d)", delay, attempt+1, opts.MaxAttempts)
+			time.Sleep(delay)
+		}
+		if err = sendToCollector(client, url, data); err == nil {
+			return nil
+		}
+		logrus.Debug("report submission failed:", err)
+	}
+	return err
+}
+
+// Flush drains the offline queue, retrying each spooled report in turn and
+// removing it once the collector acknowledges it. It stops at the first
+// report that still can't be delivered, leaving it (and anything queued
+// after it) for the next attempt.
+func Flush(url string, opts SendOptions) error {
+	if url == "" {
+		url = BaseURL
+	}
+	opts = opts.withDefaults()
+
+	dir, err := pendingDir()
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	client, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := sendWithRetry(client, url, data, opts); err != nil {
+			return fmt.Errorf("couldn't flush queued report %s: %w", f.Name(), err)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		logrus.Debug("flushed queued report", path)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package sysmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	defer func(base time.Duration) { backoffBase = base }(backoffBase)
+	backoffBase = time.Millisecond
+
+	prev := time.Duration(0)
+	for attempt := 0; attempt < 20; attempt++ {
+		base := backoffBase * time.Duration(1<<uint(attempt))
+		if base > backoffCap || base <= 0 {
+			base = backoffCap
+		}
+
+		got := backoffDelay(attempt)
+		if got < base || got > base+backoffBase {
+			t.Errorf("attempt %d: got %s, want between %s and %s", attempt, got, base, base+backoffBase)
+		}
+		if attempt > 0 && got < prev-backoffBase {
+			t.Errorf("attempt %d: got %s, expected backoff to grow or stay capped, previous was %s", attempt, got, prev)
+		}
+		prev = got
+	}
+}
+
+func TestSendWithRetry(t *testing.T) {
+	defer func(base time.Duration) { backoffBase = base }(backoffBase)
+	backoffBase = time.Millisecond
+
+	testCases := []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		wantErr     bool
+		wantHits    int
+	}{
+		{"succeeds on first attempt", 0, 3, false, 1},
+		{"recovers after two failures", 2, 3, false, 3},
+		{"gives up after exhausting attempts", 3, 3, true, 3},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			hits := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				if hits <= tc.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer ts.Close()
+
+			opts := SendOptions{MaxAttempts: tc.maxAttempts}.withDefaults()
+			client, err := newHTTPClient(opts)
+			if err != nil {
+				t.Fatalf("couldn't build HTTP client: %v", err)
+			}
+
+			err = sendWithRetry(client, ts.URL, []byte(`{}`), opts)
+			if tc.wantErr && err == nil {
+				t.Error("expected an error, got none")
+			}
+			if !tc.wantErr && err != nil {
+				t.Error("expected no error, got:", err)
+			}
+			if hits != tc.wantHits {
+				t.Errorf("got %d requests to the collector, want %d", hits, tc.wantHits)
+			}
+		})
+	}
+}
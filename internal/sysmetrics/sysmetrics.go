@@ -0,0 +1,135 @@
+// Package sysmetrics orchestrates collecting the local system metrics,
+// persisting them to the on-disk report cache and sending them to the
+// ubuntu-report collector.
+package sysmetrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ubuntu/ubuntu-report/internal/metrics"
+)
+
+// BaseURL is the default collector endpoint used when none is provided on
+// the command line.
+const BaseURL = "https://metrics.ubuntu.com/ubuntu/desktop"
+
+// OptOutJSON is the payload stored and sent when the user opts out of
+// reporting entirely.
+const OptOutJSON = `{"OptOut": true}`
+
+// Collect gathers the current system metrics, applying filter, and returns
+// them serialized to JSON.
+func Collect(filter metrics.CategoryFilter) (json.RawMessage, error) {
+	return metrics.New(filter).Collect()
+}
+
+// CacheDir returns the directory the per-distribution report is cached
+// into, creating it if needed.
+func CacheDir() (string, error) {
+	xdgCache := os.Getenv("XDG_CACHE_HOME")
+	if xdgCache == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		xdgCache = filepath.Join(home, ".cache")
+	}
+	dir := filepath.Join(xdgCache, "ubuntu-report")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// reportFileName returns the distribution-version identifier used to name
+// the cache file, so that upgrading the distribution allows reporting
+// again.
+func reportFileName() string {
+	id, version := "unknown", "unknown"
+	if f, err := os.Open("/etc/os-release"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			l := scanner.Text()
+			switch {
+			case strings.HasPrefix(l, "ID="):
+				id = strings.Trim(strings.TrimPrefix(l, "ID="), `"`)
+			case strings.HasPrefix(l, "VERSION_ID="):
+				version = strings.Trim(strings.TrimPrefix(l, "VERSION_ID="), `"`)
+			}
+		}
+	}
+	return fmt.Sprintf("%s.%s", id, version)
+}
+
+// WriteCache persists data (a report or the opt-out marker) to the on-disk
+// cache, so that a given distribution version is only reported once.
+func WriteCache(data []byte) error {
+	dir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, reportFileName()), data, 0600)
+}
+
+// sendToCollector POSTs data to url, returning an error if it couldn't be
+// delivered or wasn't acknowledged with a 2xx status code.
+func sendToCollector(client *http.Client, url string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector at %s answered with status %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Report sends data to url, retrying with exponential backoff on failure.
+// Any previously queued reports are flushed first. If data still can't be
+// delivered after opts.MaxAttempts, it is spooled to the offline queue for a
+// later send or flush instead of being dropped.
+func Report(url string, data []byte, opts SendOptions) error {
+	if url == "" {
+		url = BaseURL
+	}
+	opts = opts.withDefaults()
+
+	if err := Flush(url, opts); err != nil {
+		logrus.Debug("couldn't flush offline queue:", err)
+	}
+
+	client, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	if err := sendWithRetry(client, url, data, opts); err != nil {
+		path, qerr := enqueue(data)
+		if qerr != nil {
+			return qerr
+		}
+		logrus.Debugf("couldn't deliver report, queued for later at %s: %v", path, err)
+		return nil
+	}
+
+	logrus.Debug("report sent to", url)
+	return WriteCache(data)
+}
@@ -0,0 +1,78 @@
+package sysmetrics
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// TLSOptions controls how the HTTP client used to reach the collector
+// validates (and authenticates to) the server.
+type TLSOptions struct {
+	// PinsSHA256 lists base64-encoded SHA-256 hashes of the collector's
+	// accepted Subject Public Key Info. At least one must match a
+	// certificate in the presented chain when set.
+	PinsSHA256 []string
+	// ClientCertFile and ClientKeyFile, when both set, authenticate this
+	// client to the collector via mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+	// InsecureSkipVerify disables the usual hostname and certificate chain
+	// verification. Pin verification (if configured) still applies.
+	InsecureSkipVerify bool
+}
+
+// newHTTPClient builds the http.Client used to talk to the collector,
+// applying the certificate pinning and mTLS options carried by opts.
+func newHTTPClient(opts SendOptions) (*http.Client, error) {
+	client := &http.Client{Timeout: opts.Timeout}
+
+	tlsOpts := opts.TLS
+	if len(tlsOpts.PinsSHA256) == 0 && tlsOpts.ClientCertFile == "" && !tlsOpts.InsecureSkipVerify {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: tlsOpts.InsecureSkipVerify}
+
+	if tlsOpts.ClientCertFile != "" || tlsOpts.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsOpts.ClientCertFile, tlsOpts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(tlsOpts.PinsSHA256) > 0 {
+		tlsConfig.VerifyPeerCertificate = verifySPKIPins(tlsOpts.PinsSHA256)
+	}
+
+	client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	return client, nil
+}
+
+// verifySPKIPins returns a tls.Config.VerifyPeerCertificate callback
+// requiring that at least one certificate in the presented chain has a
+// Subject Public Key Info whose SHA-256 hash matches one of pins.
+func verifySPKIPins(pins []string) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	pinned := make(map[string]bool, len(pins))
+	for _, p := range pins {
+		pinned[p] = true
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if pinned[base64.StdEncoding.EncodeToString(sum[:])] {
+				return nil
+			}
+		}
+		return fmt.Errorf("no certificate in the chain matched any pinned SPKI hash")
+	}
+}
@@ -0,0 +1,109 @@
+package sysmetrics
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ubuntu/ubuntu-report/internal/metrics"
+)
+
+// requiredCategories lists the top-level keys a non-opt-out report must
+// carry, unless it is listed in the report's own Excluded field.
+var requiredCategories = []string{
+	"Version", "OEM", "BIOS", "CPU", "Arch", "RAM", "Autologin", "LivePatch",
+	"Session", "Locale", "Timezone", "Install", "Upgrade",
+}
+
+// optionalCategories lists top-level keys that a best-effort collector may
+// legitimately come back empty for on real hardware (no GPU enumerated, no
+// screen detected, and so on), so Validate never flags their absence on its
+// own. They are only ever missing for one of two reasons: the collector
+// found nothing, or the reporter excluded them, and validate has no way to
+// tell those apart from the serialized report alone.
+var optionalCategories = []string{"GPU", "Disks", "Partitions", "Screens"}
+
+// ValidationIssue describes one way a report failed validation.
+type ValidationIssue struct {
+	Field string `json:"Field"`
+	Issue string `json:"Issue"`
+}
+
+// ValidationResult is the outcome of validating a report.
+type ValidationResult struct {
+	Valid  bool              `json:"Valid"`
+	Issues []ValidationIssue `json:"Issues,omitempty"`
+}
+
+// Validate checks that data is either a valid opt-out marker or a report
+// carrying every required category with a sane type, returning every issue
+// found rather than stopping at the first one.
+func Validate(data []byte) ValidationResult {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ValidationResult{Issues: []ValidationIssue{{Issue: fmt.Sprintf("not a valid JSON object: %v", err)}}}
+	}
+
+	if raw, ok := m["OptOut"]; ok {
+		var optOut bool
+		if err := json.Unmarshal(raw, &optOut); err != nil {
+			return ValidationResult{Issues: []ValidationIssue{{Field: "OptOut", Issue: "must be a boolean"}}}
+		}
+		if optOut {
+			if len(m) != 1 {
+				return ValidationResult{Issues: []ValidationIssue{{Field: "OptOut", Issue: "must be the only key in the report when true"}}}
+			}
+			return ValidationResult{Valid: true}
+		}
+	}
+
+	var issues []ValidationIssue
+
+	excludable := make(map[string]bool)
+	for _, key := range metrics.ExcludableCategories() {
+		excludable[key] = true
+	}
+
+	excluded := make(map[string]bool)
+	if raw, ok := m["Excluded"]; ok {
+		var names []string
+		if err := json.Unmarshal(raw, &names); err != nil {
+			issues = append(issues, ValidationIssue{Field: "Excluded", Issue: "must be an array of strings"})
+		}
+		for _, n := range names {
+			// Version (and anything else send can't actually drop) is never
+			// a legitimate exclusion: accepting it here would let a report
+			// claim away any key just by naming it, regardless of whether
+			// send could have produced that report.
+			if !excludable[n] {
+				issues = append(issues, ValidationIssue{Field: "Excluded", Issue: fmt.Sprintf("%q is not a category that can be excluded", n)})
+				continue
+			}
+			excluded[n] = true
+		}
+	}
+
+	// Categories in optionalCategories are never flagged as missing: unlike
+	// requiredCategories, their absence doesn't need an Excluded entry to be
+	// legitimate, since a best-effort collector coming back empty-handed
+	// looks identical on the wire.
+	for _, key := range requiredCategories {
+		if _, ok := m[key]; ok || excluded[key] {
+			continue
+		}
+		issues = append(issues, ValidationIssue{Field: key, Issue: "missing required key"})
+	}
+
+	var r metrics.Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		issues = append(issues, ValidationIssue{Issue: fmt.Sprintf("couldn't decode report: %v", err)})
+		return ValidationResult{Issues: issues}
+	}
+
+	if _, ok := m["Session"]; ok {
+		if r.Session.DE == "" || r.Session.Name == "" || r.Session.Type == "" {
+			issues = append(issues, ValidationIssue{Field: "Session", Issue: "DE, Name and Type must all be non-empty"})
+		}
+	}
+
+	return ValidationResult{Valid: len(issues) == 0, Issues: issues}
+}